@@ -1,10 +1,13 @@
 package gock
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
-	"runtime/debug"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -20,6 +23,25 @@ func (g GoFunc) NoErr(f func()) {
 	})
 }
 
+// An Option configures a bundle created by BundleOpts.
+type Option func(*bundleConfig)
+
+type bundleConfig struct {
+	limit int
+}
+
+// WithLimit caps the number of functions passed to g that may run
+// concurrently at n, mirroring errgroup.Group.SetLimit. Once n functions are
+// in flight, g blocks until one of them returns; the tryGo function returned
+// by BundleOpts returns false instead of blocking.
+//
+// A non-positive n means no limit, which is the default.
+func WithLimit(n int) Option {
+	return func(c *bundleConfig) {
+		c.limit = n
+	}
+}
+
 // Bundle returns a function g to run functions concurrently, and a
 // function wait to wait for all the functions provided to g to return before
 // returning itself. Thus, the provided functions run in a "bundle" of
@@ -39,34 +61,140 @@ func (g GoFunc) NoErr(f func()) {
 // method Unwrap() error to recover the original value, if it was an error.
 //
 // You may prefer Wait, which is a shortcut.
+//
+// See BundleWithContext if you need sibling goroutines to observe the first
+// failure as it happens, rather than only once wait returns, and BundleOpts
+// if you need to cap how many of the functions passed to g run concurrently.
 func Bundle() (g GoFunc, wait func() error) {
+	g, _, wait, _ = newBundle(context.Background(), bundleConfig{})
+	return g, wait
+}
+
+// BundleWithContext works like Bundle, but additionally derives ctx into a
+// context that is cancelled as soon as one of the functions passed to g
+// returns a non-nil error or panics. This mirrors the errgroup.WithContext
+// pattern: pass the returned context down to the functions run through g so
+// that siblings can bail out as soon as one of them fails, instead of only
+// finding out once wait returns.
+//
+// wait keeps aggregating every error via AddConcurrentError, exactly as in
+// Bundle; cancellation doesn't change what wait returns, only how early
+// sibling goroutines can react.
+func BundleWithContext(ctx context.Context) (g GoFunc, wait func() error, derived context.Context) {
+	g, _, wait, derived = newBundle(ctx, bundleConfig{})
+	return g, wait, derived
+}
+
+// BundleOpts works like Bundle, but accepts Options to configure the
+// returned bundle, such as WithLimit to cap how many of the functions passed
+// to g run concurrently.
+//
+// It additionally returns tryGo, a variant of g that, once the configured
+// limit has been reached, returns false instead of blocking until a slot
+// frees up.
+func BundleOpts(opts ...Option) (g GoFunc, tryGo func(f func() error) bool, wait func() error) {
+	var cfg bundleConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	g, tryGo, wait, _ = newBundle(context.Background(), cfg)
+	return g, tryGo, wait
+}
+
+func newBundle(ctx context.Context, cfg bundleConfig) (g GoFunc, tryGo func(f func() error) bool, wait func() error, derived context.Context) {
+	derived, cancel := context.WithCancel(ctx)
+
 	errs := make(chan error)
 	panics := make(chan capturedPanic)
 
+	var sem chan struct{}
+	if cfg.limit > 0 {
+		sem = make(chan struct{}, cfg.limit)
+	}
+
 	var (
 		mtx       sync.Mutex
 		callCount int64
 		waited    bool
 	)
 
+	spawn := func(f func() error) {
+		go func() {
+			defer func() {
+				if r := recover(); r != nil {
+					if sem != nil {
+						<-sem
+					}
+					cancel()
+
+					// If r is already a capturedPanic, eg. because f
+					// recovered its own panic to share it with other
+					// callers (as Group.Do does) and repanicked it
+					// unchanged, forward it as is instead of wrapping it
+					// again and losing the original panic site's stack.
+					if cp, ok := r.(capturedPanic); ok {
+						panics <- cp
+					} else {
+						panics <- capturedPanic{r, callers()}
+					}
+				}
+			}()
+			err := f()
+
+			// Free the slot as soon as f is done, not once the result has
+			// been picked up by wait, so that a slow wait doesn't starve
+			// the limit.
+			if sem != nil {
+				<-sem
+			}
+
+			if err != nil {
+				cancel()
+			}
+			errs <- err
+		}()
+	}
+
 	g = func(f func() error) {
 		mtx.Lock()
-		defer mtx.Unlock()
+		if waited {
+			mtx.Unlock()
+			panic("gock: bundle already finished")
+		}
+		callCount++
+		mtx.Unlock()
+
+		// Block on the semaphore, if any, without holding mtx, so that
+		// tryGo and wait aren't blocked behind a full bundle.
+		if sem != nil {
+			sem <- struct{}{}
+		}
+
+		spawn(f)
+	}
+
+	tryGo = func(f func() error) bool {
+		mtx.Lock()
 
 		if waited {
+			mtx.Unlock()
 			panic("gock: bundle already finished")
 		}
 
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			default:
+				mtx.Unlock()
+				return false
+			}
+		}
+
 		callCount++
+		mtx.Unlock()
 
-		go func() {
-			defer func() {
-				if r := recover(); r != nil {
-					panics <- capturedPanic{r, debug.Stack()}
-				}
-			}()
-			errs <- f()
-		}()
+		spawn(f)
+		return true
 	}
 
 	var waitErr error
@@ -79,6 +207,7 @@ func Bundle() (g GoFunc, wait func() error) {
 			if callCount == 0 {
 				waited = true
 				mtx.Unlock()
+				cancel()
 				return waitErr
 			}
 
@@ -95,16 +224,48 @@ func Bundle() (g GoFunc, wait func() error) {
 		}
 	}
 
-	return g, wait
+	return g, tryGo, wait, derived
 }
 
 type capturedPanic struct {
 	p     interface{}
-	stack []byte
+	stack []uintptr
 }
 
 func (p capturedPanic) Error() string {
-	return fmt.Sprintf("gock: managed goroutine panicked: %v\n\noriginal stack:\n\n%s", p.p, p.stack)
+	return fmt.Sprintf("gock: managed goroutine panicked: %v", p.p)
+}
+
+// StackTrace returns the frames of the stack at the point where the original
+// panic was recovered, innermost frame first.
+func (p capturedPanic) StackTrace() StackTrace {
+	frames := make(StackTrace, len(p.stack))
+	for i, pc := range p.stack {
+		frames[i] = Frame(pc)
+	}
+	return frames
+}
+
+// Format implements fmt.Formatter. %v and %s print the same single-line
+// message as Error. %+v additionally prints the stack captured at the point
+// of the panic, one frame per line.
+func (p capturedPanic) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, p.Error())
+			for _, f := range p.StackTrace() {
+				io.WriteString(s, "\n")
+				f.Format(s, 'v')
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, p.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", p.Error())
+	}
 }
 
 func (p capturedPanic) Unwrap() error {
@@ -116,6 +277,91 @@ func (p capturedPanic) Unwrap() error {
 	}
 }
 
+// callers captures the stack of a panic being recovered, skipping its four
+// innermost frames (runtime.Callers, callers itself, the deferred function
+// calling it and runtime.gopanic), so that the first frame is the one that
+// called panic. The frames below the panic site, including the gock
+// goroutine that ran the function and runtime.goexit, are kept.
+func callers() []uintptr {
+	const depth = 32
+	var pcs [depth]uintptr
+	n := runtime.Callers(4, pcs[:])
+	return pcs[:n]
+}
+
+// A Frame represents a single entry in a StackTrace, identified by its
+// program counter.
+type Frame uintptr
+
+func (f Frame) pc() uintptr { return uintptr(f) - 1 }
+
+func (f Frame) file() string {
+	fn := runtime.FuncForPC(f.pc())
+	if fn == nil {
+		return "unknown"
+	}
+	file, _ := fn.FileLine(f.pc())
+	return file
+}
+
+func (f Frame) line() int {
+	fn := runtime.FuncForPC(f.pc())
+	if fn == nil {
+		return 0
+	}
+	_, line := fn.FileLine(f.pc())
+	return line
+}
+
+func (f Frame) name() string {
+	fn := runtime.FuncForPC(f.pc())
+	if fn == nil {
+		return "unknown"
+	}
+	return fn.Name()
+}
+
+// Format implements fmt.Formatter.
+//
+// %s	function name
+// %d	source line
+// %n	bare function name, without its package qualifier
+// %v	source file and line, as file:line
+// %+v	the function name followed by its file:line
+func (f Frame) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 's':
+		io.WriteString(s, f.name())
+	case 'd':
+		io.WriteString(s, strconv.Itoa(f.line()))
+	case 'n':
+		io.WriteString(s, funcname(f.name()))
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, f.name())
+			io.WriteString(s, " ")
+		}
+		io.WriteString(s, f.file())
+		io.WriteString(s, ":")
+		io.WriteString(s, strconv.Itoa(f.line()))
+	}
+}
+
+// funcname strips the package path from a runtime function name, eg.
+// "github.com/tcard/gock.Wait" becomes "Wait".
+func funcname(name string) string {
+	i := strings.LastIndex(name, "/")
+	name = name[i+1:]
+	i = strings.Index(name, ".")
+	return name[i+1:]
+}
+
+// A StackTrace is a slice of Frames, innermost frame first, as captured by a
+// recovered panic. Use StackTrace on a capturedPanic (eg. via errors.As with
+// an interface exposing the method) to get at it programmatically, instead
+// of parsing the textual stack dump that %+v produces.
+type StackTrace []Frame
+
 var nopFunc = func() error { return nil }
 
 // Wait runs the provided functions concurrently. It waits for all of them to
@@ -136,6 +382,20 @@ func Wait(fs ...func() error) error {
 	return wait()
 }
 
+// WaitWithContext works like Wait, but additionally derives ctx into a
+// context that is cancelled as soon as one of the functions returns a
+// non-nil error or panics, following the errgroup.WithContext pattern. The
+// derived context is returned alongside the aggregated error; by the time
+// WaitWithContext returns, it has been cancelled whether or not any of the
+// functions failed.
+func WaitWithContext(ctx context.Context, fs ...func() error) (err error, derived context.Context) {
+	g, wait, derived := BundleWithContext(ctx)
+	for _, f := range fs {
+		g(f)
+	}
+	return wait(), derived
+}
+
 // AddConcurrentError merges two concurrent, possibly nil errors.
 //
 // If both are nil, nil is returned.
@@ -190,6 +450,45 @@ func (errs ConcurrentErrors) Error() string {
 	return fmt.Sprintf("concurrent errors: %s", strings.Join(ss, "; "))
 }
 
+// Format implements fmt.Formatter. %v and %s print the same single-line
+// message as Error. %+v prints a header line followed by each inner error on
+// its own indented line, rendered with %+v so that errors that themselves
+// have a verbose representation, like a capturedPanic's stack trace or a
+// nested ConcurrentErrors, cascade. %#v prints a Go-syntax representation of
+// the underlying slice.
+func (errs ConcurrentErrors) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('#') {
+			fmt.Fprintf(s, "%#v", errs.Errors)
+			return
+		}
+		if s.Flag('+') {
+			fmt.Fprintf(s, "concurrent errors (%d):", len(errs.Errors))
+			for _, err := range errs.Errors {
+				io.WriteString(s, "\n\t")
+				io.WriteString(s, indentTail(fmt.Sprintf("%+v", err), "\t"))
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, errs.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", errs.Error())
+	}
+}
+
+// indentTail prefixes every line but the first of s with prefix, so that a
+// multi-line inner message nests cleanly under the line it's printed after.
+func indentTail(s string, prefix string) string {
+	lines := strings.Split(s, "\n")
+	for i := 1; i < len(lines); i++ {
+		lines[i] = prefix + lines[i]
+	}
+	return strings.Join(lines, "\n")
+}
+
 // Unwrap returns, if it exists, the common ancestor among the error chains of
 // all errors contained in the ConcurrentErrors.
 func (errs ConcurrentErrors) Unwrap() error {
@@ -221,6 +520,76 @@ func (errs ConcurrentErrors) Unwrap() error {
 	return nil
 }
 
+// Leaves walks the error tree rooted at err, expanding any ConcurrentErrors
+// it finds, at any depth reached via Unwrap, into each of the errors it
+// aggregates, and returns the flat list of terminal (ie. those whose
+// Unwrap returns nil), non-ConcurrentErrors leaves it reaches, in
+// deterministic order.
+//
+// This is the tool to reach for when AnyIs/AnyAs aren't enough, eg. to
+// collect every gRPC status.Code produced across a Bundle, or to render a
+// tree of causes, without re-implementing the unwrap/type-switch dance
+// yourself.
+func Leaves(err error) []error {
+	var leaves []error
+	Walk(err, func(err error) bool {
+		leaves = append(leaves, err)
+		return true
+	})
+	return leaves
+}
+
+// Walk traverses the error tree rooted at err. Whenever it reaches a
+// ConcurrentErrors, at any depth, it recurses into each of its Errors
+// instead of treating the ConcurrentErrors as a node itself. For every
+// other, terminal error it reaches (ie. whose Unwrap returns nil), it calls
+// visit. Walk stops as soon as visit returns false.
+//
+// It reuses the same cycle-avoidance approach as ConcurrentErrors.Unwrap (a
+// timesFound map) to guard against self-referential chains.
+func Walk(err error, visit func(error) bool) {
+	timesFound := map[error]int{}
+	walk(err, visit, timesFound)
+}
+
+func walk(err error, visit func(error) bool, timesFound map[error]int) bool {
+	if err == nil {
+		return true
+	}
+
+	if cerrs, ok := err.(ConcurrentErrors); ok {
+		for _, sub := range cerrs.Errors {
+			// Each branch gets its own copy of what's been seen so far:
+			// cycle detection only needs to guard a single branch's own
+			// linear descent, not dedup identical sentinel values that
+			// happen to show up independently across sibling branches.
+			branchSeen := make(map[error]int, len(timesFound))
+			for k, v := range timesFound {
+				branchSeen[k] = v
+			}
+			if !walk(sub, visit, branchSeen) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if reflect.TypeOf(err).Comparable() {
+		if timesFound[err] > 0 {
+			// Already walked from here; avoid looping forever on a
+			// self-referential chain.
+			return true
+		}
+		timesFound[err]++
+	}
+
+	if next := unwrap(err); next != nil {
+		return walk(next, visit, timesFound)
+	}
+
+	return visit(err)
+}
+
 // AnyIs returns whether any of the concurrent errors bundlded in err is the
 // given error, as defined by errors.Is.
 func AnyIs(err, target error) bool {
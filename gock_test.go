@@ -1,10 +1,15 @@
 package gock_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"reflect"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/tcard/gock"
 )
@@ -56,6 +61,31 @@ func ExampleWait_sameErrorTwice() {
 	// true
 }
 
+func ExampleWait_commonErrorAncestor() {
+	var ErrCommonAncestor = errors.New("ye eldest")
+
+	err := gock.Wait(func() error {
+		return fmt.Errorf(
+			"first in first chain: %w",
+			fmt.Errorf(
+				"second in first chain: %w",
+				ErrCommonAncestor,
+			),
+		)
+	}, func() error {
+		return nil
+	}, func() error {
+		return fmt.Errorf(
+			"first in second chain: %w",
+			ErrCommonAncestor,
+		)
+	})
+
+	fmt.Println(errors.Is(err, ErrCommonAncestor))
+	// Output:
+	// true
+}
+
 func TestGoRunsBeforeWait(t *testing.T) {
 	g, wait := gock.Bundle()
 	defer wait()
@@ -76,6 +106,122 @@ func TestGoAfterWait(t *testing.T) {
 	}()
 }
 
+func TestBundleWithContextCancelsOnFirstError(t *testing.T) {
+	expected := errors.New("expect me")
+	g, wait, ctx := gock.BundleWithContext(context.Background())
+
+	siblingSawDone := make(chan struct{})
+	g(func() error {
+		select {
+		case <-ctx.Done():
+			close(siblingSawDone)
+		case <-time.After(time.Second):
+			t.Error("sibling never observed ctx.Done()")
+		}
+		return nil
+	})
+	g(func() error {
+		return expected
+	})
+
+	<-siblingSawDone
+	if err := wait(); !errors.Is(err, expected) {
+		t.Errorf("expected %v in the aggregated error, got %v", expected, err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the derived context to be cancelled")
+	}
+}
+
+func TestBundleWithContextCancelsOnPanic(t *testing.T) {
+	g, wait, ctx := gock.BundleWithContext(context.Background())
+
+	siblingSawDone := make(chan struct{})
+	g(func() error {
+		select {
+		case <-ctx.Done():
+			close(siblingSawDone)
+		case <-time.After(time.Second):
+			t.Error("sibling never observed ctx.Done()")
+		}
+		return nil
+	})
+	g(func() error {
+		panic("boom")
+	})
+
+	<-siblingSawDone
+	func() {
+		defer func() { recover() }()
+		wait()
+		t.Error("expected wait to repanic")
+	}()
+}
+
+func TestWaitWithContext(t *testing.T) {
+	expected := errors.New("expect me")
+	err, ctx := gock.WaitWithContext(context.Background(), func() error {
+		return expected
+	}, func() error {
+		return nil
+	})
+	if !errors.Is(err, expected) {
+		t.Errorf("expected %v, got %v", expected, err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the derived context to be cancelled")
+	}
+}
+
+func TestBundleOptsLimit(t *testing.T) {
+	const limit = 3
+	const total = 10
+
+	g, _, wait := gock.BundleOpts(gock.WithLimit(limit))
+
+	var live, maxLive int64
+	for i := 0; i < total; i++ {
+		g.NoErr(func() {
+			n := atomic.AddInt64(&live, 1)
+			for {
+				old := atomic.LoadInt64(&maxLive)
+				if n <= old || atomic.CompareAndSwapInt64(&maxLive, old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt64(&live, -1)
+		})
+	}
+	wait()
+
+	if maxLive > limit {
+		t.Errorf("expected at most %d functions concurrently live, got %d", limit, maxLive)
+	}
+}
+
+func TestBundleOptsTryGo(t *testing.T) {
+	g, tryGo, wait := gock.BundleOpts(gock.WithLimit(1))
+
+	started := make(chan struct{})
+	block := make(chan struct{})
+	g(func() error {
+		close(started)
+		<-block
+		return nil
+	})
+	<-started
+
+	if tryGo(func() error { return nil }) {
+		t.Error("expected tryGo to return false while the limit is reached")
+	}
+
+	close(block)
+	if err := wait(); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
 func TestIdempotentWait(t *testing.T) {
 	expected := errors.New("expect me")
 	g, wait := gock.Bundle()
@@ -117,6 +263,45 @@ func TestConcurrentErrorsString(t *testing.T) {
 	}
 }
 
+func TestConcurrentErrorsFormatVerbose(t *testing.T) {
+	cerr := gock.AddConcurrentError(errors.New("foo"), errors.New("bar")).(gock.ConcurrentErrors)
+
+	if oneLine := fmt.Sprintf("%v", cerr); oneLine != cerr.Error() {
+		t.Errorf("expected %%v to match Error(), got %q vs %q", oneLine, cerr.Error())
+	}
+
+	verbose := fmt.Sprintf("%+v", cerr)
+	if !strings.Contains(verbose, "\n\tfoo") || !strings.Contains(verbose, "\n\tbar") {
+		t.Errorf("expected each error on its own indented line, got: %q", verbose)
+	}
+
+	if goSyntax := fmt.Sprintf("%#v", cerr); !strings.HasPrefix(goSyntax, "[]error{") {
+		t.Errorf("expected %%#v to be a Go slice literal, got: %q", goSyntax)
+	}
+}
+
+type multilineError string
+
+func (e multilineError) Error() string { return "summary: " + string(e) }
+
+func (e multilineError) Format(s fmt.State, verb rune) {
+	if verb == 'v' && s.Flag('+') {
+		io.WriteString(s, e.Error())
+		io.WriteString(s, "\n\tframe 1\n\tframe 2")
+		return
+	}
+	io.WriteString(s, e.Error())
+}
+
+func TestConcurrentErrorsFormatCascades(t *testing.T) {
+	cerr := gock.AddConcurrentError(errors.New("plain"), multilineError("boom")).(gock.ConcurrentErrors)
+
+	verbose := fmt.Sprintf("%+v", cerr)
+	if !strings.Contains(verbose, "\n\t\tframe 1") || !strings.Contains(verbose, "\n\t\tframe 2") {
+		t.Errorf("expected the inner multi-line error's frames to be nested, got: %q", verbose)
+	}
+}
+
 func TestConcurrentErrorsFlatten(t *testing.T) {
 	errs := []error{errors.New("foo"), errors.New("bar"), errors.New("baz")}
 	cerrs := gock.AddConcurrentError(
@@ -234,13 +419,90 @@ func TestAnyAsSingle(t *testing.T) {
 	}
 }
 
+func TestLeavesFlattensNestedConcurrentErrors(t *testing.T) {
+	a, b, c := errors.New("a"), errors.New("b"), errors.New("c")
+	nested := gock.AddConcurrentError(b, c)
+	err := gock.AddConcurrentError(a, nested)
+
+	leaves := gock.Leaves(err)
+	if expected, got := 3, len(leaves); expected != got {
+		t.Fatalf("expected %d leaves, got %d: %v", expected, got, leaves)
+	}
+}
+
+func TestLeavesReturnsTerminalCause(t *testing.T) {
+	root := errors.New("root cause")
+	wrapped := fmt.Errorf("wrapping: %w", root)
+
+	err := gock.AddConcurrentError(wrapped, errors.New("other"))
+	leaves := gock.Leaves(err)
+
+	found := false
+	for _, l := range leaves {
+		if l == root {
+			found = true
+		}
+		if l == wrapped {
+			t.Errorf("expected the wrapper to not itself be a leaf, only its terminal cause")
+		}
+	}
+	if !found {
+		t.Errorf("expected to find the terminal cause among leaves, got: %v", leaves)
+	}
+}
+
+func TestWalkStopsOnFalse(t *testing.T) {
+	err := gock.AddConcurrentError(errors.New("a"), errors.New("b"))
+
+	var visited []error
+	gock.Walk(err, func(e error) bool {
+		visited = append(visited, e)
+		return false
+	})
+	if len(visited) != 1 {
+		t.Errorf("expected Walk to stop after the first visit, got %d", len(visited))
+	}
+}
+
+func TestLeavesDoesNotDedupSameSentinelAcrossSiblings(t *testing.T) {
+	sentinel := errors.New("sentinel")
+	cerrs := gock.ConcurrentErrors{Errors: []error{sentinel, sentinel, sentinel}}
+
+	leaves := gock.Leaves(cerrs)
+	if expected, got := 3, len(leaves); expected != got {
+		t.Errorf("expected %d leaves, one per sibling, got %d: %v", expected, got, leaves)
+	}
+}
+
+func TestWalkRecursesIntoConcurrentErrorsReachedViaUnwrap(t *testing.T) {
+	a, b := errors.New("a"), errors.New("b")
+	cerrs := gock.ConcurrentErrors{Errors: []error{a, b}}
+	err := fmt.Errorf("wrapping: %w", cerrs)
+
+	leaves := gock.Leaves(err)
+	if expected, got := 2, len(leaves); expected != got {
+		t.Fatalf("expected %d leaves reached by unwrapping into the nested ConcurrentErrors, got %d: %v", expected, got, leaves)
+	}
+	for _, want := range []error{a, b} {
+		found := false
+		for _, l := range leaves {
+			if l == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected %v among the leaves, got %v", want, leaves)
+		}
+	}
+}
+
 func TestConcurrentErrorsUnwrapNoCommonAncestor(t *testing.T) {
 	ancestor := errors.New("ancestor")
 	err := gock.AddConcurrentError(
 		chain{errors.New("foo"), ancestor},
 		chain{errors.New("baz"), errors.New("another ancestor")},
 	)
-	ok := errorsIs(err, ancestor)
+	ok := errors.Is(err, ancestor)
 	if ok {
 		t.Errorf("didn't expect to find the non-common ancestor")
 	}
@@ -319,7 +581,7 @@ func TestPanic(t *testing.T) {
 			defer func() {
 				r := recover()
 				err, ok := r.(error)
-				if !ok || !errorsIs(err, expectedErr) {
+				if !ok || !errors.Is(err, expectedErr) {
 					t.Errorf("expected repanic of expectedErr in the blocked goroutine, got: %v", r)
 				}
 			}()
@@ -329,6 +591,79 @@ func TestPanic(t *testing.T) {
 	}
 }
 
+type stackTracer interface {
+	StackTrace() gock.StackTrace
+}
+
+func TestCapturedPanicStackTrace(t *testing.T) {
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok {
+			t.Fatalf("expected error, got %v (%T)", r, r)
+		}
+
+		var st stackTracer
+		if !errors.As(err, &st) {
+			t.Fatal("expected to fish a StackTrace out of the panic via errors.As")
+		}
+		if len(st.StackTrace()) == 0 {
+			t.Fatal("expected a non-empty stack trace")
+		}
+
+		oneLine := fmt.Sprintf("%v", err)
+		if strings.Contains(oneLine, "\n") {
+			t.Errorf("expected %%v to stay single-line, got: %q", oneLine)
+		}
+		if oneLine != err.Error() {
+			t.Errorf("expected %%v to match Error(), got %q vs %q", oneLine, err.Error())
+		}
+
+		verbose := fmt.Sprintf("%+v", err)
+		if !strings.HasPrefix(verbose, err.Error()) {
+			t.Errorf("expected %%+v to start with Error(), got: %q", verbose)
+		}
+		if lines := strings.Split(verbose, "\n"); len(lines) < 2 {
+			t.Errorf("expected %%+v to include frame lines, got: %q", verbose)
+		}
+	}()
+
+	gock.Wait(func() error {
+		panic(errors.New("boom"))
+	})
+}
+
+func TestFrameFormat(t *testing.T) {
+	defer func() {
+		r := recover()
+		err := r.(error)
+
+		var st stackTracer
+		errors.As(err, &st)
+		f := st.StackTrace()[0]
+
+		if s := fmt.Sprintf("%d", f); s == "" || s == "0" {
+			t.Errorf("expected a non-zero line number, got %q", s)
+		}
+		if s := fmt.Sprintf("%s", f); !strings.Contains(s, "TestFrameFormat") {
+			t.Errorf("expected the function name to contain TestFrameFormat, got %q", s)
+		}
+		if s := fmt.Sprintf("%n", f); strings.Contains(s, "gock_test.") {
+			t.Errorf("expected %%n to strip the package qualifier, got %q", s)
+		}
+		if s := fmt.Sprintf("%v", f); !strings.Contains(s, ":") {
+			t.Errorf("expected %%v to look like file:line, got %q", s)
+		}
+		if s := fmt.Sprintf("%+v", f); !strings.Contains(s, "TestFrameFormat") || !strings.Contains(s, ":") {
+			t.Errorf("expected %%+v to include the function and file:line, got %q", s)
+		}
+	}()
+
+	gock.Wait(func() error {
+		panic("boom")
+	})
+}
+
 func TestNoErr(t *testing.T) {
 	g, wait := gock.Bundle()
 
@@ -0,0 +1,147 @@
+// +build go1.18
+
+package gock
+
+import "sync"
+
+// A Result is the outcome of a call made through Group.DoChan.
+type Result[V any] struct {
+	Val    V
+	Err    error
+	Shared bool
+}
+
+type call[V any] struct {
+	wg    sync.WaitGroup
+	val   V
+	err   error
+	panic *capturedPanic
+}
+
+// A Group provides singleflight-style deduplication for fan-in workloads:
+// Do runs a given function at most once per in-flight key, sharing its
+// result (and any panic) with every concurrent caller for that key.
+//
+// The zero value isn't ready to use; construct one with NewGroup.
+type Group[K comparable, V any] struct {
+	mtx   sync.Mutex
+	calls map[K]*call[V]
+	g     GoFunc
+}
+
+// NewGroup returns an empty Group.
+//
+// Without binding it to a Bundle via BindTo, the first caller for a key runs
+// fn in its own goroutine, and a panic in fn is repanicked, wrapped with its
+// captured stack, in every caller sharing that key.
+func NewGroup[K comparable, V any]() *Group[K, V] {
+	return &Group[K, V]{calls: make(map[K]*call[V])}
+}
+
+// BindTo binds the group to a bundle's g. Once bound, the first caller for a
+// key runs fn through g instead of a bare goroutine: its error feeds
+// AddConcurrentError as usual, and a panic propagates through wait exactly
+// as for any other function run through g, in addition to being repanicked
+// in the callers sharing that key.
+func (gr *Group[K, V]) BindTo(g GoFunc) {
+	gr.mtx.Lock()
+	defer gr.mtx.Unlock()
+	gr.g = g
+}
+
+// Do runs fn and returns its result, unless a call for key is already in
+// flight, in which case it waits for that call and shares its result
+// instead. shared reports whether the result came from a call made by
+// another caller.
+//
+// If fn panics, the recovered value is repanicked, wrapped in a
+// capturedPanic that keeps the original stack trace, in every caller
+// sharing the key. If the group is bound to a bundle via BindTo, the panic
+// also propagates through that bundle's wait, same as any other function run
+// through g.
+func (gr *Group[K, V]) Do(key K, fn func() (V, error)) (v V, err error, shared bool) {
+	gr.mtx.Lock()
+	if c, ok := gr.calls[key]; ok {
+		gr.mtx.Unlock()
+		c.wg.Wait()
+		if c.panic != nil {
+			panic(*c.panic)
+		}
+		return c.val, c.err, true
+	}
+
+	c := &call[V]{}
+	c.wg.Add(1)
+	gr.calls[key] = c
+	g := gr.g
+	gr.mtx.Unlock()
+
+	run := gr.runner(key, c, fn, g != nil)
+	if g != nil {
+		g(run)
+	} else {
+		go func() { run() }()
+	}
+
+	c.wg.Wait()
+	if c.panic != nil {
+		panic(*c.panic)
+	}
+	return c.val, c.err, false
+}
+
+func (gr *Group[K, V]) runner(key K, c *call[V], fn func() (V, error), repanic bool) func() error {
+	return func() (err error) {
+		defer func() {
+			gr.mtx.Lock()
+			if gr.calls[key] == c {
+				delete(gr.calls, key)
+			}
+			gr.mtx.Unlock()
+		}()
+
+		defer func() {
+			if r := recover(); r != nil {
+				p := capturedPanic{r, callers()}
+				c.panic = &p
+				c.wg.Done()
+				if repanic {
+					panic(p)
+				}
+			}
+		}()
+
+		c.val, err = fn()
+		c.err = err
+		c.wg.Done()
+		return err
+	}
+}
+
+// DoChan works like Do, but returns a channel that receives the Result
+// instead of blocking. If fn panics, the captured panic is reported as
+// ch's Result.Err rather than repanicked, since there's no caller goroutine
+// to repanic into.
+func (gr *Group[K, V]) DoChan(key K, fn func() (V, error)) <-chan Result[V] {
+	ch := make(chan Result[V], 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err, _ := r.(error)
+				ch <- Result[V]{Err: err}
+			}
+		}()
+		v, err, shared := gr.Do(key, fn)
+		ch <- Result[V]{Val: v, Err: err, Shared: shared}
+	}()
+	return ch
+}
+
+// Forget removes key from the in-flight set, so that a subsequent Do call
+// for key runs fn again instead of joining any call currently in flight for
+// it.
+func (gr *Group[K, V]) Forget(key K) {
+	gr.mtx.Lock()
+	defer gr.mtx.Unlock()
+	delete(gr.calls, key)
+}
@@ -0,0 +1,263 @@
+// +build go1.18
+
+package gock_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tcard/gock"
+)
+
+func TestGroupDoDedup(t *testing.T) {
+	gr := gock.NewGroup[string, int]()
+
+	var calls int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]int, 5)
+	shareds := make([]bool, 5)
+	for i := 0; i < 5; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err, shared := gr.Do("key", func() (int, error) {
+				if atomic.AddInt64(&calls, 1) == 1 {
+					close(started)
+				}
+				<-release
+				return 42, nil
+			})
+			if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			results[i] = v
+			shareds[i] = shared
+		}()
+	}
+
+	<-started
+	// Give the other callers a chance to join the in-flight call before
+	// releasing it.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected fn to run once, ran %d times", calls)
+	}
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("result %d: expected 42, got %d", i, v)
+		}
+	}
+	sharedCount := 0
+	for _, s := range shareds {
+		if s {
+			sharedCount++
+		}
+	}
+	if sharedCount != 4 {
+		t.Errorf("expected 4 shared results, got %d", sharedCount)
+	}
+}
+
+func TestGroupDoPanicSharedAcrossCallers(t *testing.T) {
+	gr := gock.NewGroup[string, int]()
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	var panicked int64
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					atomic.AddInt64(&panicked, 1)
+				}
+			}()
+			<-start
+			gr.Do("key", func() (int, error) {
+				panic("boom")
+			})
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if panicked != 3 {
+		t.Errorf("expected all 3 callers to observe the panic, got %d", panicked)
+	}
+}
+
+func TestGroupBoundToBundlePropagatesThroughWait(t *testing.T) {
+	g, wait := gock.Bundle()
+	gr := gock.NewGroup[string, int]()
+	gr.BindTo(g)
+
+	expected := errors.New("boom")
+	if v, err, _ := gr.Do("key", func() (int, error) {
+		return 0, expected
+	}); v != 0 || !errors.Is(err, expected) {
+		t.Errorf("unexpected Do result: %v, %v", v, err)
+	}
+
+	if waitErr := wait(); !errors.Is(waitErr, expected) {
+		t.Errorf("expected wait to also see the error via AddConcurrentError, got: %v", waitErr)
+	}
+}
+
+func TestGroupBoundToBundlePropagatesPanicThroughWait(t *testing.T) {
+	g, wait := gock.Bundle()
+	gr := gock.NewGroup[string, int]()
+	gr.BindTo(g)
+
+	expected := errors.New("expected")
+	func() {
+		defer func() { recover() }()
+		gr.Do("key", func() (int, error) {
+			panic(expected)
+		})
+	}()
+
+	func() {
+		defer func() {
+			r := recover()
+			err, ok := r.(error)
+			if !ok {
+				t.Fatalf("expected error, got %T", r)
+			}
+			if !errors.Is(err, expected) {
+				t.Errorf("expected errors.Is to find the original panic value through Unwrap, got: %v", err)
+			}
+		}()
+		wait()
+	}()
+}
+
+func panicAtThisGroupTestSite() {
+	panic("boom")
+}
+
+func TestGroupBoundPanicKeepsOriginalStackSite(t *testing.T) {
+	g, wait := gock.Bundle()
+	gr := gock.NewGroup[string, int]()
+	gr.BindTo(g)
+
+	func() {
+		defer func() { recover() }()
+		gr.Do("key", func() (int, error) {
+			panicAtThisGroupTestSite()
+			return 0, nil
+		})
+	}()
+
+	func() {
+		defer func() {
+			r := recover()
+			err, ok := r.(error)
+			if !ok {
+				t.Fatalf("expected error, got %T", r)
+			}
+
+			if n := strings.Count(err.Error(), "gock: managed goroutine panicked:"); n > 1 {
+				t.Errorf("expected the panic to not be wrapped twice, got: %q", err.Error())
+			}
+
+			var st stackTracer
+			if !errors.As(err, &st) {
+				t.Fatal("expected to fish a StackTrace out via errors.As")
+			}
+			frames := st.StackTrace()
+			if len(frames) == 0 {
+				t.Fatal("expected a non-empty stack trace")
+			}
+			if top := fmt.Sprintf("%s", frames[0]); !strings.Contains(top, "panicAtThisGroupTestSite") {
+				t.Errorf("expected the innermost frame to be the original panic site, got: %q (full: %+v)", top, err)
+			}
+		}()
+		wait()
+	}()
+}
+
+func TestGroupForget(t *testing.T) {
+	gr := gock.NewGroup[string, int]()
+
+	var calls int64
+	gr.Do("key", func() (int, error) {
+		atomic.AddInt64(&calls, 1)
+		return 1, nil
+	})
+	gr.Forget("key")
+	gr.Do("key", func() (int, error) {
+		atomic.AddInt64(&calls, 1)
+		return 2, nil
+	})
+
+	if calls != 2 {
+		t.Errorf("expected fn to run twice after Forget, ran %d times", calls)
+	}
+}
+
+func TestGroupForgetWhileInFlight(t *testing.T) {
+	gr := gock.NewGroup[string, int]()
+
+	var calls int64
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	firstDone := make(chan struct{})
+	go func() {
+		defer close(firstDone)
+		gr.Do("key", func() (int, error) {
+			atomic.AddInt64(&calls, 1)
+			close(started)
+			<-release
+			return 1, nil
+		})
+	}()
+
+	<-started
+	gr.Forget("key")
+
+	var secondShared bool
+	secondDone := make(chan struct{})
+	go func() {
+		defer close(secondDone)
+		_, _, secondShared = gr.Do("key", func() (int, error) {
+			atomic.AddInt64(&calls, 1)
+			return 2, nil
+		})
+	}()
+	<-secondDone
+
+	close(release)
+	<-firstDone
+
+	if calls != 2 {
+		t.Errorf("expected fn to run twice, once for each Do not sharing the forgotten call, ran %d times", calls)
+	}
+	if secondShared {
+		t.Error("expected the second Do not to share the result of the call that was Forgotten while in flight")
+	}
+}
+
+func TestGroupDoChan(t *testing.T) {
+	gr := gock.NewGroup[string, int]()
+	ch := gr.DoChan("key", func() (int, error) {
+		return 7, nil
+	})
+	res := <-ch
+	if res.Val != 7 || res.Err != nil {
+		t.Errorf("unexpected result: %+v", res)
+	}
+}